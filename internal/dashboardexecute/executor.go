@@ -16,11 +16,12 @@ import (
 	"github.com/turbot/powerpipe/internal/dashboardevents"
 	"github.com/turbot/powerpipe/internal/dashboardtypes"
 	"github.com/turbot/powerpipe/internal/db_client"
+	"github.com/turbot/powerpipe/internal/logging"
 	"github.com/turbot/powerpipe/internal/workspace"
 )
 
 type DashboardExecutor struct {
-	// map of executions, keyed by session id
+	// map of executions running on this node, keyed by session id
 	executions    map[string]*DashboardExecutionTree
 	executionLock sync.Mutex
 	// is this an interactive execution
@@ -30,15 +31,68 @@ type DashboardExecutor struct {
 	// store the default client which is created during initData creation
 	// - this is to avoid creating a new client for each dashboard execution if the database/search path is NOT overridden
 	defaultClient *db_client.ClientMap
+
+	// sessionStore holds cross-node session state and event fan-out, so that websocket
+	// clients are not pinned to the node that started their execution. Defaults to an
+	// in-memory store; pass WithSessionStore(NewRedisSessionStore(...)) to share
+	// sessions across a cluster of powerpipe nodes.
+	sessionStore SessionStore
+	// leaseReleases holds the release function for the session lease held by this
+	// node, for sessions whose execution is actually running here
+	leaseReleases map[string]func()
+
+	// logger is the structured logger for this executor, named "dashboard.exec"
+	logger logging.Logger
+}
+
+// DashboardExecutorOption defines a type of function to configure the DashboardExecutor.
+type DashboardExecutorOption func(*DashboardExecutor)
+
+// WithSessionStore sets the SessionStore used to share session state and fan out
+// dashboardevents.* across nodes. Defaults to an in-memory store if not set.
+func WithSessionStore(store SessionStore) DashboardExecutorOption {
+	return func(e *DashboardExecutor) {
+		e.sessionStore = store
+	}
+}
+
+// WithLogger sets the structured logger used by the executor, in place of the default
+// logging.NewLogger("dashboard.exec").
+func WithLogger(logger logging.Logger) DashboardExecutorOption {
+	return func(e *DashboardExecutor) {
+		e.logger = logger
+	}
 }
 
-func NewDashboardExecutor(defaultClient *db_client.ClientMap) *DashboardExecutor {
-	return &DashboardExecutor{
+// WithInteractive overrides whether this executor is interactive (inputs may be set
+// after execution starts) or running in batch mode, where all required inputs must be
+// supplied up front. Defaults to true (interactive).
+func WithInteractive(interactive bool) DashboardExecutorOption {
+	return func(e *DashboardExecutor) {
+		e.interactive = interactive
+	}
+}
+
+func NewDashboardExecutor(defaultClient *db_client.ClientMap, opts ...DashboardExecutorOption) *DashboardExecutor {
+	e := &DashboardExecutor{
 		executions: make(map[string]*DashboardExecutionTree),
 		// default to interactive execution
 		interactive:   true,
 		defaultClient: defaultClient,
+		sessionStore:  newMemorySessionStore(),
+		leaseReleases: make(map[string]func()),
+		logger:        logging.NewLogger("dashboard.exec"),
+	}
+	for _, opt := range opts {
+		opt(e)
 	}
+	return e
+}
+
+// sessionLogger returns a child logger named "dashboard.exec.session=<id>", for log
+// lines scoped to a single session's execution.
+func (e *DashboardExecutor) sessionLogger(sessionId string) logging.Logger {
+	return e.logger.Named(fmt.Sprintf("session=%s", sessionId))
 }
 
 var Executor *DashboardExecutor
@@ -55,18 +109,33 @@ func (e *DashboardExecutor) ExecuteDashboard(ctx context.Context, sessionId stri
 		}
 		// if there was an error executing, send an ExecutionError event
 		if err != nil {
+			e.sessionLogger(sessionId).Error("execution error", "error", err)
 			errorEvent := &dashboardevents.ExecutionError{
 				Error:     err,
 				Session:   sessionId,
 				Timestamp: time.Now(),
 			}
 			workspace.PublishDashboardEvent(ctx, errorEvent)
+			_ = e.sessionStore.PublishEvent(ctx, sessionId, errorEvent)
 		}
 	}()
 
 	// reset any existing executions for this session
 	e.CancelExecutionForSession(ctx, sessionId)
 
+	// ensure exactly one node runs this session's execution tree - if another node
+	// already holds the lease, leave it to run there
+	leaseOk, release, err := e.sessionStore.AcquireLease(ctx, sessionId, defaultLeaseTTL)
+	if err != nil {
+		return fmt.Errorf("failed to acquire session lease: %w", err)
+	}
+	if !leaseOk {
+		return fmt.Errorf("dashboard for session %s is already running on another node", sessionId)
+	}
+	e.executionLock.Lock()
+	e.leaseReleases[sessionId] = release
+	e.executionLock.Unlock()
+
 	// now create a new execution
 	executionTree, err = newDashboardExecutionTree(rootResource, sessionId, workspace, e.defaultClient, opts...)
 	if err != nil {
@@ -82,12 +151,32 @@ func (e *DashboardExecutor) ExecuteDashboard(ctx context.Context, sessionId stri
 	// add to execution map
 	e.setExecution(sessionId, executionTree)
 
+	// record session state in the shared store so other nodes (and a client that
+	// reconnects to one) can see this session is in flight
+	_ = e.sessionStore.Put(ctx, &SessionState{
+		SessionId:   sessionId,
+		ExecutionId: executionTree.id,
+		RunStatus:   fmt.Sprintf("%v", executionTree.GetRunStatus()),
+		InputValues: inputs,
+	})
+
 	// if inputs have been passed, set them first
 	if len(inputs) > 0 {
 		executionTree.SetInputValues(inputs)
 	}
 
-	go executionTree.Execute(ctx)
+	sessionLogger := e.sessionLogger(sessionId)
+	sessionLogger.Info("execution start", "execution_id", executionTree.id)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				sessionLogger.Error("execution panicked", "execution_id", executionTree.id, "panic", r)
+			}
+		}()
+		executionTree.Execute(ctx)
+		sessionLogger.Info("execution complete", "execution_id", executionTree.id, "status", fmt.Sprintf("%v", executionTree.GetRunStatus()))
+	}()
 
 	return nil
 }
@@ -138,6 +227,15 @@ func (e *DashboardExecutor) LoadSnapshot(ctx context.Context, sessionId, snapsho
 		return nil, err
 	}
 
+	// cache the loaded panels in the shared session store, so a subsequent load of the
+	// same session on a different node doesn't need to re-read the file from disk
+	existing, found, _ := e.sessionStore.Get(ctx, sessionId)
+	if !found {
+		existing = &SessionState{SessionId: sessionId}
+	}
+	existing.Panels = snap
+	_ = e.sessionStore.Put(ctx, existing)
+
 	return snap, nil
 }
 
@@ -159,6 +257,8 @@ func (e *DashboardExecutor) OnInputChanged(ctx context.Context, sessionId string
 			ExecutionId:   executionTree.id,
 		}
 		executionTree.workspace.PublishDashboardEvent(ctx, event)
+		_ = e.sessionStore.PublishEvent(ctx, sessionId, event)
+		e.sessionLogger(sessionId).Info("input cleared", "execution_id", executionTree.id, "changed_input", changedInput, "cleared_inputs", clearedInputs)
 	}
 	// if there are any dependent inputs, set their value to nil and send an event to the UI
 	// if the dashboard run is complete, just re-execute
@@ -194,18 +294,95 @@ func (e *DashboardExecutor) clearDependentInputs(root dashboardtypes.DashboardTr
 	return clearedInputs
 }
 
-func (e *DashboardExecutor) CancelExecutionForSession(_ context.Context, sessionId string) {
+func (e *DashboardExecutor) CancelExecutionForSession(ctx context.Context, sessionId string) {
 	// find the execution
+	executionTree, found := e.getExecution(sessionId)
+	if found {
+		e.sessionLogger(sessionId).Info("execution cancel", "execution_id", executionTree.id)
+		// cancel if in progress
+		executionTree.Cancel()
+		// remove from execution tree
+		e.removeExecution(sessionId)
+	}
+
+	// give up this node's leadership and clear the shared session state/lease, but
+	// only if this node actually held the lease - ExecuteDashboard calls this on every
+	// invocation (including on other nodes, via reconnect/OnInputChanged re-execution),
+	// so unconditionally deleting here would let any node evict another node's active
+	// lease and immediately win AcquireLease itself, producing two nodes running
+	// executionTree.Execute concurrently for the same session
+	e.executionLock.Lock()
+	release, haveLease := e.leaseReleases[sessionId]
+	delete(e.leaseReleases, sessionId)
+	e.executionLock.Unlock()
+	if haveLease {
+		release()
+		_ = e.sessionStore.Delete(ctx, sessionId)
+	}
+}
+
+// IsSessionFinished returns true once sessionId's execution has finished (or if no
+// such execution is tracked on this node at all).
+func (e *DashboardExecutor) IsSessionFinished(sessionId string) bool {
 	executionTree, found := e.getExecution(sessionId)
 	if !found {
-		// nothing to do
-		return
+		return true
 	}
+	return executionTree.GetRunStatus().IsFinished()
+}
 
-	// cancel if in progress
-	executionTree.Cancel()
-	// remove from execution tree
-	e.removeExecution(sessionId)
+// Snapshot returns the serialized panels map for sessionId's completed execution, in
+// the same shape LoadSnapshot returns.
+func (e *DashboardExecutor) Snapshot(sessionId string) (map[string]any, error) {
+	executionTree, found := e.getExecution(sessionId)
+	if !found {
+		return nil, fmt.Errorf("no execution found for session %s", sessionId)
+	}
+
+	data, err := json.Marshal(executionTree.AsSteampipeSnapshot())
+	if err != nil {
+		return nil, err
+	}
+
+	snap := map[string]any{}
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// DefaultClient returns the default db client map this executor was created with, so
+// that a scoped (e.g. batch) executor can be created sharing the same connections.
+func (e *DashboardExecutor) DefaultClient() *db_client.ClientMap {
+	return e.defaultClient
+}
+
+// SessionStore returns the SessionStore this executor uses, so a scoped executor can
+// share session state/event fan-out.
+func (e *DashboardExecutor) SessionStore() SessionStore {
+	return e.sessionStore
+}
+
+// SubscribeSessionEvents subscribes to the dashboardevents.* fan-out for sessionId via
+// the executor's SessionStore, regardless of which node is actually running the
+// session's execution tree.
+func (e *DashboardExecutor) SubscribeSessionEvents(ctx context.Context, sessionId string) (<-chan []byte, func(), error) {
+	return e.sessionStore.SubscribeEvents(ctx, sessionId)
+}
+
+// CancelAll cancels every execution currently tracked by the executor. It is called
+// during service shutdown so that in-flight dashboard runs don't leak goroutines.
+func (e *DashboardExecutor) CancelAll(ctx context.Context) {
+	e.executionLock.Lock()
+	sessionIds := make([]string, 0, len(e.executions))
+	for sessionId := range e.executions {
+		sessionIds = append(sessionIds, sessionId)
+	}
+	e.executionLock.Unlock()
+
+	for _, sessionId := range sessionIds {
+		e.CancelExecutionForSession(ctx, sessionId)
+	}
 }
 
 // find the execution for the given session id
@@ -0,0 +1,154 @@
+package dashboardexecute
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// SessionState is the portion of a dashboard execution's state that needs to be
+// visible to every node, so that a client reconnecting to a different node can
+// resume (or at least inspect) a session started elsewhere.
+type SessionState struct {
+	SessionId   string
+	ExecutionId string
+	RunStatus   string
+	InputValues map[string]any
+	// Panels holds the serialized snapshot map of a session loaded from a snapshot
+	// file via LoadSnapshot, in the same shape LoadSnapshot returns. It is not set
+	// when an execution finishes.
+	Panels map[string]any `json:",omitempty"`
+}
+
+// SessionStore abstracts where DashboardExecutor keeps cross-node session state and
+// how it fans out dashboardevents.* for a session. The default, used when
+// NewDashboardExecutor is not given WithSessionStore, is an in-memory store which only
+// works within a single process; RedisSessionStore is the distributed implementation.
+type SessionStore interface {
+	// Get returns the last known state for sessionId, if any.
+	Get(ctx context.Context, sessionId string) (*SessionState, bool, error)
+	// Put persists (or replaces) the state for sessionId.
+	Put(ctx context.Context, state *SessionState) error
+	// Delete removes the session state held for sessionId. It does NOT touch that
+	// session's lease - the lease is only ever safely released by the release func
+	// AcquireLease returns, which (for a distributed store) compare-and-deletes against
+	// the token it acquired the lease with, so a lapsed holder can never delete a lease
+	// another node has since won.
+	Delete(ctx context.Context, sessionId string) error
+	// PublishEvent JSON-encodes a dashboardevents.* event and fans it out to every
+	// subscriber of sessionId, on this node and (for a distributed store) on every
+	// other node.
+	PublishEvent(ctx context.Context, sessionId string, event any) error
+	// SubscribeEvents returns a channel of JSON-encoded dashboardevents.* events
+	// published for sessionId, and an unsubscribe function that must be called once
+	// the caller is done reading. Events are left JSON-encoded (rather than decoded
+	// back into concrete event types) since that is also the shape the /ws websocket
+	// handler needs to forward them to clients in.
+	SubscribeEvents(ctx context.Context, sessionId string) (<-chan []byte, func(), error)
+	// AcquireLease attempts to become the leader responsible for actually running
+	// sessionId's execution tree, so that exactly one node does the work. ok is false
+	// if another node already holds the lease. release must be called once execution
+	// finishes (or is cancelled) to give up leadership.
+	AcquireLease(ctx context.Context, sessionId string, ttl time.Duration) (ok bool, release func(), err error)
+}
+
+// defaultLeaseTTL is refreshed periodically by the leader for the lifetime of an
+// execution; it only needs to be long enough to survive a missed refresh tick.
+const defaultLeaseTTL = 30 * time.Second
+
+// memorySessionStore is the default SessionStore: it keeps everything in-process, so
+// it is only correct when powerpipe is run as a single node.
+type memorySessionStore struct {
+	mu          sync.Mutex
+	states      map[string]*SessionState
+	subscribers map[string][]chan []byte
+	leases      map[string]bool
+}
+
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{
+		states:      make(map[string]*SessionState),
+		subscribers: make(map[string][]chan []byte),
+		leases:      make(map[string]bool),
+	}
+}
+
+func (s *memorySessionStore) Get(_ context.Context, sessionId string) (*SessionState, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[sessionId]
+	return state, ok, nil
+}
+
+func (s *memorySessionStore) Put(_ context.Context, state *SessionState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[state.SessionId] = state
+	return nil
+}
+
+func (s *memorySessionStore) Delete(_ context.Context, sessionId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, sessionId)
+	return nil
+}
+
+func (s *memorySessionStore) PublishEvent(_ context.Context, sessionId string, event any) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ch := range s.subscribers[sessionId] {
+		select {
+		case ch <- data:
+		default:
+			// subscriber isn't keeping up - drop rather than block the publisher
+		}
+	}
+	return nil
+}
+
+func (s *memorySessionStore) SubscribeEvents(_ context.Context, sessionId string) (<-chan []byte, func(), error) {
+	ch := make(chan []byte, 64)
+
+	s.mu.Lock()
+	s.subscribers[sessionId] = append(s.subscribers[sessionId], ch)
+	s.mu.Unlock()
+
+	unsubscribe := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		subs := s.subscribers[sessionId]
+		for i, c := range subs {
+			if c == ch {
+				s.subscribers[sessionId] = append(subs[:i], subs[i+1:]...)
+				break
+			}
+		}
+		close(ch)
+	}
+
+	return ch, unsubscribe, nil
+}
+
+func (s *memorySessionStore) AcquireLease(_ context.Context, sessionId string, _ time.Duration) (bool, func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.leases[sessionId] {
+		return false, nil, nil
+	}
+	s.leases[sessionId] = true
+
+	release := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.leases, sessionId)
+	}
+	return true, release, nil
+}
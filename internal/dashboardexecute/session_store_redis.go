@@ -0,0 +1,182 @@
+package dashboardexecute
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// releaseLeaseScript deletes the lease key only if it still holds the token this
+// holder acquired it with, so a holder whose lease lapsed (missed refresh, GC pause)
+// and was since re-acquired by another node can't delete that node's lease out from
+// under it - the same compare-and-delete Redlock recommends for safe release.
+var releaseLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+else
+	return 0
+end
+`)
+
+// refreshLeaseScript extends the lease key's TTL only if it still holds the token this
+// holder acquired it with.
+var refreshLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+else
+	return 0
+end
+`)
+
+// RedisSessionStore is the distributed SessionStore implementation: session state is
+// kept JSON-encoded in a plain Redis string key, not a hash (one key per session),
+// and events are fanned out via Redis
+// pub/sub, so that a dashboard session started on one powerpipe node is visible - and,
+// via AcquireLease, runnable - on every node sharing the same Redis instance.
+type RedisSessionStore struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+// NewRedisSessionStore returns a SessionStore backed by client. keyPrefix namespaces
+// all keys this store creates (e.g. "powerpipe:dashboard:"), so multiple deployments
+// can share a Redis instance.
+func NewRedisSessionStore(client *redis.Client, keyPrefix string) *RedisSessionStore {
+	return &RedisSessionStore{client: client, keyPrefix: keyPrefix}
+}
+
+func (s *RedisSessionStore) stateKey(sessionId string) string {
+	return s.keyPrefix + "session:" + sessionId
+}
+
+func (s *RedisSessionStore) eventChannel(sessionId string) string {
+	return s.keyPrefix + "events:" + sessionId
+}
+
+func (s *RedisSessionStore) leaseKey(sessionId string) string {
+	return s.keyPrefix + "lease:" + sessionId
+}
+
+func (s *RedisSessionStore) Get(ctx context.Context, sessionId string) (*SessionState, bool, error) {
+	data, err := s.client.Get(ctx, s.stateKey(sessionId)).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("redis session store: get %s: %w", sessionId, err)
+	}
+
+	var state SessionState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, false, fmt.Errorf("redis session store: decode %s: %w", sessionId, err)
+	}
+	return &state, true, nil
+}
+
+func (s *RedisSessionStore) Put(ctx context.Context, state *SessionState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("redis session store: encode %s: %w", state.SessionId, err)
+	}
+	if err := s.client.Set(ctx, s.stateKey(state.SessionId), data, 0).Err(); err != nil {
+		return fmt.Errorf("redis session store: put %s: %w", state.SessionId, err)
+	}
+	return nil
+}
+
+// Delete removes only the session state key, never the lease key - the lease can only
+// be safely released via the CAS-checked release func AcquireLease returns (see
+// releaseLeaseScript), so a node whose lease has lapsed can't delete a lease another
+// node has since acquired.
+func (s *RedisSessionStore) Delete(ctx context.Context, sessionId string) error {
+	if err := s.client.Del(ctx, s.stateKey(sessionId)).Err(); err != nil {
+		return fmt.Errorf("redis session store: delete %s: %w", sessionId, err)
+	}
+	return nil
+}
+
+func (s *RedisSessionStore) PublishEvent(ctx context.Context, sessionId string, event any) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("redis session store: encode event for %s: %w", sessionId, err)
+	}
+	if err := s.client.Publish(ctx, s.eventChannel(sessionId), data).Err(); err != nil {
+		return fmt.Errorf("redis session store: publish event for %s: %w", sessionId, err)
+	}
+	return nil
+}
+
+// SubscribeEvents subscribes to sessionId's Redis pub/sub channel, forwarding the raw
+// JSON payload of each published dashboardevents.* event.
+func (s *RedisSessionStore) SubscribeEvents(ctx context.Context, sessionId string) (<-chan []byte, func(), error) {
+	pubsub := s.client.Subscribe(ctx, s.eventChannel(sessionId))
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, nil, fmt.Errorf("redis session store: subscribe %s: %w", sessionId, err)
+	}
+
+	out := make(chan []byte, 64)
+	redisCh := pubsub.Channel()
+	done := make(chan struct{})
+
+	go func() {
+		defer close(out)
+		for msg := range redisCh {
+			select {
+			case out <- []byte(msg.Payload):
+			case <-done:
+				// consumer stopped reading (e.g. unsubscribe was called) - drop the
+				// message rather than block forever on a full, undrained channel
+			}
+		}
+	}()
+
+	unsubscribe := func() {
+		_ = pubsub.Close()
+		close(done)
+	}
+	return out, unsubscribe, nil
+}
+
+// AcquireLease takes the Redis lease key with SET NX + expiry, so exactly one node at
+// a time believes it should run the execution tree for sessionId. The lease is
+// refreshed every ttl/3 until release is called.
+func (s *RedisSessionStore) AcquireLease(ctx context.Context, sessionId string, ttl time.Duration) (bool, func(), error) {
+	// a random token per acquisition lets refresh/release prove they still own the
+	// lease, rather than blindly renewing/deleting whatever key happens to be there -
+	// if the TTL lapses and another node wins SETNX in between, our token no longer
+	// matches and refresh/release become no-ops instead of clobbering the new holder
+	token := uuid.NewString()
+
+	ok, err := s.client.SetNX(ctx, s.leaseKey(sessionId), token, ttl).Result()
+	if err != nil {
+		return false, nil, fmt.Errorf("redis session store: acquire lease %s: %w", sessionId, err)
+	}
+	if !ok {
+		return false, nil, nil
+	}
+
+	refreshCtx, cancelRefresh := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-refreshCtx.Done():
+				return
+			case <-ticker.C:
+				refreshLeaseScript.Run(refreshCtx, s.client, []string{s.leaseKey(sessionId)}, token, ttl.Milliseconds())
+			}
+		}
+	}()
+
+	release := func() {
+		cancelRefresh()
+		releaseLeaseScript.Run(context.Background(), s.client, []string{s.leaseKey(sessionId)}, token)
+	}
+	return true, release, nil
+}
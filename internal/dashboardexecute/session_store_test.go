@@ -0,0 +1,116 @@
+package dashboardexecute
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMemorySessionStore_AcquireLease(t *testing.T) {
+	store := newMemorySessionStore()
+	ctx := context.Background()
+
+	ok, release, err := store.AcquireLease(ctx, "session-1", time.Second)
+	if err != nil || !ok {
+		t.Fatalf("expected first AcquireLease to succeed, got ok=%v err=%v", ok, err)
+	}
+
+	if ok, _, err := store.AcquireLease(ctx, "session-1", time.Second); err != nil || ok {
+		t.Fatalf("expected second AcquireLease for the same session to fail while held, got ok=%v err=%v", ok, err)
+	}
+
+	release()
+
+	if ok, _, err := store.AcquireLease(ctx, "session-1", time.Second); err != nil || !ok {
+		t.Fatalf("expected AcquireLease to succeed again after release, got ok=%v err=%v", ok, err)
+	}
+}
+
+func newTestRedisSessionStore(t *testing.T) (*RedisSessionStore, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	return NewRedisSessionStore(client, "test:"), mr
+}
+
+func TestRedisSessionStore_AcquireLease(t *testing.T) {
+	store, _ := newTestRedisSessionStore(t)
+	ctx := context.Background()
+
+	ok, release, err := store.AcquireLease(ctx, "session-1", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("expected first AcquireLease to succeed, got ok=%v err=%v", ok, err)
+	}
+	defer release()
+
+	if ok, _, err := store.AcquireLease(ctx, "session-1", time.Minute); err != nil || ok {
+		t.Fatalf("expected second AcquireLease for the same session to fail while held, got ok=%v err=%v", ok, err)
+	}
+}
+
+// TestRedisSessionStore_ReleaseDoesNotEvictAnotherHoldersLease is a regression test for
+// the split-brain bug fixed in 89cd99b/b8ff823: if node A's lease lapses (missed
+// refresh, GC pause) and node B wins the next AcquireLease, node A's (stale) release
+// func must NOT delete node B's lease - it no longer holds the token the lease key was
+// last written with.
+func TestRedisSessionStore_ReleaseDoesNotEvictAnotherHoldersLease(t *testing.T) {
+	store, mr := newTestRedisSessionStore(t)
+	ctx := context.Background()
+
+	// node A acquires, with a short TTL so we can simulate it lapsing
+	okA, releaseA, err := store.AcquireLease(ctx, "session-1", 50*time.Millisecond)
+	if err != nil || !okA {
+		t.Fatalf("expected node A's AcquireLease to succeed, got ok=%v err=%v", okA, err)
+	}
+
+	// let the lease expire without node A refreshing it (simulating a missed tick)
+	mr.FastForward(100 * time.Millisecond)
+
+	// node B now wins the lease
+	okB, releaseB, err := store.AcquireLease(ctx, "session-1", time.Minute)
+	if err != nil || !okB {
+		t.Fatalf("expected node B's AcquireLease to succeed after node A's lease lapsed, got ok=%v err=%v", okB, err)
+	}
+	defer releaseB()
+
+	// node A's stale release must be a no-op against node B's lease
+	releaseA()
+
+	if okC, _, err := store.AcquireLease(ctx, "session-1", time.Minute); err != nil || okC {
+		t.Fatalf("expected node A's release to leave node B's lease intact, but a third AcquireLease succeeded (ok=%v err=%v)", okC, err)
+	}
+}
+
+// TestRedisSessionStore_DeleteDoesNotTouchLease is a regression test: Delete must only
+// remove session state, never the lease key - only the CAS-checked release func
+// returned by AcquireLease may remove a lease, so a node whose lease has lapsed can't
+// delete a lease another node has since won.
+func TestRedisSessionStore_DeleteDoesNotTouchLease(t *testing.T) {
+	store, _ := newTestRedisSessionStore(t)
+	ctx := context.Background()
+
+	okA, _, err := store.AcquireLease(ctx, "session-1", time.Minute)
+	if err != nil || !okA {
+		t.Fatalf("expected AcquireLease to succeed, got ok=%v err=%v", okA, err)
+	}
+
+	if err := store.Put(ctx, &SessionState{SessionId: "session-1"}); err != nil {
+		t.Fatalf("unexpected error from Put: %v", err)
+	}
+
+	if err := store.Delete(ctx, "session-1"); err != nil {
+		t.Fatalf("unexpected error from Delete: %v", err)
+	}
+
+	if _, found, err := store.Get(ctx, "session-1"); err != nil || found {
+		t.Fatalf("expected session state to be gone after Delete, found=%v err=%v", found, err)
+	}
+
+	// the lease must still be held - a second AcquireLease must fail
+	if ok, _, err := store.AcquireLease(ctx, "session-1", time.Minute); err != nil || ok {
+		t.Fatalf("expected Delete to leave the lease intact, but AcquireLease succeeded (ok=%v err=%v)", ok, err)
+	}
+}
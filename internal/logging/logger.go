@@ -0,0 +1,30 @@
+// Package logging provides the module-wide structured logger used by the API and
+// dashboard execution services, built on hashicorp/go-hclog so output is consistent
+// with the rest of the pipe-fittings/flowpipe family.
+package logging
+
+import (
+	"os"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/spf13/viper"
+)
+
+// Logger is the structured logger type used throughout powerpipe's service layer.
+type Logger = hclog.Logger
+
+// NewLogger returns a named Logger configured from viper (log.level, log.format),
+// defaulting to human-readable output at info level.
+func NewLogger(name string) Logger {
+	level := hclog.LevelFromString(viper.GetString("log.level"))
+	if level == hclog.NoLevel {
+		level = hclog.Info
+	}
+
+	return hclog.New(&hclog.LoggerOptions{
+		Name:       name,
+		Level:      level,
+		JSONFormat: viper.GetString("log.format") == "json",
+		Output:     os.Stderr,
+	})
+}
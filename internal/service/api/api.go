@@ -3,11 +3,13 @@ package api
 import (
 	"context"
 	"fmt"
-	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"path"
 	"reflect"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/didip/tollbooth/v7"
@@ -19,9 +21,14 @@ import (
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
 	"github.com/spf13/viper"
+	"golang.org/x/crypto/acme/autocert"
+
 	"github.com/turbot/pipe-fittings/filepaths"
 	"github.com/turbot/pipe-fittings/workspace"
+	"github.com/turbot/powerpipe/internal/dashboardexecute"
+	"github.com/turbot/powerpipe/internal/logging"
 	"github.com/turbot/powerpipe/internal/service/api/common"
+	ppworkspace "github.com/turbot/powerpipe/internal/workspace"
 	"gopkg.in/olahol/melody.v1"
 )
 
@@ -69,8 +76,33 @@ type APIService struct {
 	router         *gin.Engine
 	webSocket      *melody.Melody
 
+	// acmeManager is set when TLS certs are being managed via ACME/autocert
+	acmeManager *autocert.Manager
+	// acmeHTTPServer serves the ACME HTTP-01 challenge over plain HTTP, when acmeManager is set
+	acmeHTTPServer *http.Server
+
+	// drainTimeout is how long Stop waits for in-flight requests to complete. Defaults
+	// to web.shutdown.drain_timeout (or defaultDrainTimeout), overridable via
+	// WithDrainTimeout.
+	drainTimeout time.Duration
+
 	// the loaded workspace
 	workspace *workspace.Workspace
+
+	// dashboardExecutor is used to cancel in-flight dashboard executions on shutdown,
+	// and as the template (default client, session store) for scoped batch executors
+	dashboardExecutor *dashboardexecute.DashboardExecutor
+
+	// dashboardWorkspace is the powerpipe workspace used to resolve dashboards by name
+	// for the batch /execute endpoint
+	dashboardWorkspace *ppworkspace.PowerpipeWorkspace
+
+	// authProvider verifies the Authorization header of inbound API requests.
+	// Defaults to the static/HMAC provider built from viper config if not set.
+	authProvider AuthProvider
+
+	// logger is the structured logger for this service, named "api"
+	logger logging.Logger
 }
 
 // APIServiceOption defines a type of function to configures the APIService.
@@ -90,13 +122,67 @@ func WithWorkspace(workspace *workspace.Workspace) APIServiceOption {
 	}
 }
 
+func WithDashboardExecutor(executor *dashboardexecute.DashboardExecutor) APIServiceOption {
+	return func(api *APIService) error {
+		api.dashboardExecutor = executor
+		return nil
+	}
+}
+
+// WithDashboardWorkspace sets the powerpipe workspace used to resolve dashboards by
+// name for the batch /dashboard/{name}/execute endpoint.
+func WithDashboardWorkspace(ws *ppworkspace.PowerpipeWorkspace) APIServiceOption {
+	return func(api *APIService) error {
+		api.dashboardWorkspace = ws
+		return nil
+	}
+}
+
+// WithAuthProvider lets embedders supply their own Authorization header verifier,
+// instead of the default static-API-key/HMAC-token provider built from viper config.
+func WithAuthProvider(provider AuthProvider) APIServiceOption {
+	return func(api *APIService) error {
+		api.authProvider = provider
+		return nil
+	}
+}
+
+// WithDrainTimeout overrides how long Stop waits for in-flight requests to drain
+// before giving up, in place of the default (10s, or web.shutdown.drain_timeout).
+func WithDrainTimeout(timeout time.Duration) APIServiceOption {
+	return func(api *APIService) error {
+		api.drainTimeout = timeout
+		return nil
+	}
+}
+
+// WithLogger sets the structured logger used by the API service, in place of the
+// default logging.NewLogger("api").
+func WithLogger(logger logging.Logger) APIServiceOption {
+	return func(api *APIService) error {
+		api.logger = logger
+		return nil
+	}
+}
+
+// defaultDrainTimeout is how long Stop waits for in-flight requests to complete if
+// neither WithDrainTimeout nor web.shutdown.drain_timeout set one.
+const defaultDrainTimeout = 10 * time.Second
+
 // NewAPIService creates a new APIService.
 func NewAPIService(ctx context.Context, opts ...APIServiceOption) (*APIService, error) {
+	drainTimeout := defaultDrainTimeout
+	if configured := viper.GetDuration("web.shutdown.drain_timeout"); configured > 0 {
+		drainTimeout = configured
+	}
+
 	// Defaults
 	api := &APIService{
-		ctx:      ctx,
-		Status:   "initialized",
-		HTTPPort: fmt.Sprintf("%d", 9194),
+		ctx:          ctx,
+		Status:       "initialized",
+		HTTPPort:     fmt.Sprintf("%d", 9194),
+		drainTimeout: drainTimeout,
+		logger:       logging.NewLogger("api"),
 	}
 
 	// Set options
@@ -120,9 +206,12 @@ func (api *APIService) Start() error {
 
 	// Initialize gin
 	router := gin.New()
+	router.Use(gin.Recovery())
+	router.Use(RequestLoggerMiddleware(api.logger))
 
 	apiPrefixGroup := router.Group(common.APIPrefix())
 	apiPrefixGroup.Use(common.ValidateAPIVersion)
+	apiPrefixGroup.Use(api.AuthMiddleware())
 
 	// Limit the size of POST requests
 	// There doesn't seem a way to set the request size per path, but for now we have
@@ -131,7 +220,7 @@ func (api *APIService) Start() error {
 	router.Use(size.RequestSizeLimiter(viper.GetInt64("web.request.size_limit")))
 
 	// Create compression middleware - exclude process logs as we handle compression within the API itself
-	compressionMiddleware := gzip.Gzip(gzip.DefaultCompression, gzip.WithExcludedPathsRegexs([]string{"^/api/.+/.*[avatar|\\.jsonl]$"}))
+	compressionMiddleware := gzip.Gzip(gzip.DefaultCompression, gzip.WithExcludedPathsRegexs([]string{"^/api/.+/.*[avatar|\\.jsonl]$", "^/api/.+/dashboard/.+/execute$"}))
 	apiPrefixGroup.Use(compressionMiddleware)
 	router.Use(compressionMiddleware)
 
@@ -148,9 +237,13 @@ func (api *APIService) Start() error {
 	//
 	apiLimiter := tollbooth.NewLimiter(viper.GetFloat64("web.rate.fill"), &limiter.ExpirableOptions{DefaultExpirationTTL: time.Hour})
 	apiLimiter.SetBurst(viper.GetInt("web.rate.burst"))
+	apiPrefixGroup.Use(RateLimitMiddleware(apiLimiter))
 
 	RegisterPublicAPI(apiPrefixGroup)
 	api.RegisterModApiEndpoints(apiPrefixGroup)
+	if api.dashboardExecutor != nil {
+		api.RegisterDashboardExecuteEndpoint(apiPrefixGroup)
+	}
 
 	// put in handing for the dashboard for the mod
 	assetsDirectory := filepaths.EnsureDashboardAssetsDir()
@@ -162,6 +255,38 @@ func (api *APIService) Start() error {
 				c.AbortWithError(http.StatusInternalServerError, err)
 			}
 		})
+
+		// subscribe each connecting session to its dashboard execution's events, so a
+		// client that reconnects to a different node keeps receiving events for its
+		// in-flight session
+		if api.dashboardExecutor != nil {
+			api.webSocket.HandleConnect(func(s *melody.Session) {
+				sessionId := s.Request.URL.Query().Get("session")
+				if sessionId == "" {
+					return
+				}
+
+				eventCh, unsubscribe, err := api.dashboardExecutor.SubscribeSessionEvents(api.ctx, sessionId)
+				if err != nil {
+					return
+				}
+				s.Set("unsubscribe", unsubscribe)
+
+				go func() {
+					for data := range eventCh {
+						if err := s.Write(data); err != nil {
+							return
+						}
+					}
+				}()
+			})
+
+			api.webSocket.HandleDisconnect(func(s *melody.Session) {
+				if unsubscribe, ok := s.Get("unsubscribe"); ok {
+					unsubscribe.(func())()
+				}
+			})
+		}
 	}
 
 	// fall through
@@ -210,25 +335,62 @@ func (api *APIService) Start() error {
 		ReadHeaderTimeout: 60 * time.Second,
 	}
 
+	tlsConfig, err := api.configureTLS()
+	if err != nil {
+		return err
+	}
+
 	api.httpsServer = &http.Server{
 		Addr:              fmt.Sprintf("%s:%s", api.HTTPSHost, api.HTTPSPort),
 		Handler:           router,
+		TLSConfig:         tlsConfig,
 		ReadHeaderTimeout: 60 * time.Second,
 	}
 
-	// Initializing the server in a goroutine so that
+	// Initializing the servers in goroutines so that
 	// it won't block the graceful shutdown handling below
-	// go func() {
-	// 	if err := api.httpsServer.ListenAndServeTLS("./service/certificate/server.crt", "./service/certificate/server.key"); err != nil && err != http.ErrServerClosed {
-	// 		log.Fatalf("listen: %s\n", err)
-	// 	}
-	// }()
+	go func() {
+		if err := api.httpsServer.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+			api.logger.Error("https server exited", "error", err)
+		}
+	}()
 
-	// Initializing the server in a goroutine so that
-	// it won't block the graceful shutdown handling below
 	go func() {
 		if err := api.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("listen: %s\n", err)
+			api.logger.Error("http server exited", "error", err)
+		}
+	}()
+
+	// the ACME HTTP-01 challenge must be reachable over plain HTTP (normally port 80),
+	// not via api.httpServer (which already serves router on HTTPPort) or api.httpsServer
+	// (which is TLS-only) - so give the autocert manager its own listener
+	if api.acmeManager != nil {
+		acmeChallengeAddr := viper.GetString("web.tls.acme.http_addr")
+		if acmeChallengeAddr == "" {
+			acmeChallengeAddr = ":http"
+		}
+		api.acmeHTTPServer = &http.Server{
+			Addr:              acmeChallengeAddr,
+			Handler:           api.acmeManager.HTTPHandler(nil),
+			ReadHeaderTimeout: 60 * time.Second,
+		}
+		go func() {
+			if err := api.acmeHTTPServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				api.logger.Error("acme challenge server exited", "error", err)
+			}
+		}()
+	}
+
+	// handle SIGINT/SIGTERM by gracefully stopping the service rather than crashing out
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		api.logger.Info("received shutdown signal, stopping api service")
+		stopCtx, cancel := context.WithTimeout(context.Background(), api.drainTimeout)
+		defer cancel()
+		if err := api.Stop(stopCtx); err != nil {
+			api.logger.Error("error stopping api service", "error", err)
 		}
 	}()
 
@@ -237,3 +399,49 @@ func (api *APIService) Start() error {
 
 	return nil
 }
+
+// Stop gracefully shuts down the HTTP and HTTPS servers, draining in-flight requests
+// up to api.drainTimeout, cancels any dashboard executions still running against this
+// service, and closes the websocket.
+func (api *APIService) Stop(ctx context.Context) error {
+	if api.drainTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, api.drainTimeout)
+		defer cancel()
+	}
+
+	var errs []error
+
+	if api.httpServer != nil {
+		if err := api.httpServer.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("http server shutdown: %w", err))
+		}
+	}
+	if api.httpsServer != nil {
+		if err := api.httpsServer.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("https server shutdown: %w", err))
+		}
+	}
+	if api.acmeHTTPServer != nil {
+		if err := api.acmeHTTPServer.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("acme challenge server shutdown: %w", err))
+		}
+	}
+
+	if api.dashboardExecutor != nil {
+		api.dashboardExecutor.CancelAll(ctx)
+	}
+
+	if api.webSocket != nil && !api.webSocket.IsClosed() {
+		if err := api.webSocket.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("websocket close: %w", err))
+		}
+	}
+
+	api.Status = "stopped"
+
+	if len(errs) > 0 {
+		return fmt.Errorf("error(s) stopping api service: %v", errs)
+	}
+	return nil
+}
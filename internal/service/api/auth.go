@@ -0,0 +1,203 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// AuthScope is a permission granted to an API key.
+type AuthScope string
+
+const (
+	AuthScopeRead    AuthScope = "read"
+	AuthScopeExecute AuthScope = "execute"
+	AuthScopeAdmin   AuthScope = "admin"
+)
+
+// AuthIdentity describes the caller that a AuthProvider resolved an Authorization
+// header to.
+type AuthIdentity struct {
+	// KeyId identifies the API key (or token subject) used, for rate-limiting and logging.
+	KeyId  string
+	Scopes []AuthScope
+}
+
+// HasScope returns true if the identity was granted scope, or holds AuthScopeAdmin.
+func (i AuthIdentity) HasScope(scope AuthScope) bool {
+	for _, s := range i.Scopes {
+		if s == scope || s == AuthScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthProvider verifies the Authorization header of an inbound request and
+// returns the resolved identity. It returns an error if the header is missing
+// or invalid.
+type AuthProvider interface {
+	Authenticate(authHeader string) (AuthIdentity, error)
+}
+
+// apiKeyAuthProvider is the default AuthProvider, backed by a static set of API
+// keys loaded from viper (web.auth.api_keys, a map of key -> scopes) and,
+// optionally, HMAC-signed bearer tokens verified against web.auth.token_secret.
+type apiKeyAuthProvider struct {
+	apiKeys     map[string][]AuthScope
+	tokenSecret []byte
+}
+
+func newAPIKeyAuthProviderFromViper() *apiKeyAuthProvider {
+	apiKeys := make(map[string][]AuthScope)
+	for key, rawScopes := range viper.GetStringMapStringSlice("web.auth.api_keys") {
+		scopes := make([]AuthScope, len(rawScopes))
+		for i, s := range rawScopes {
+			scopes[i] = AuthScope(s)
+		}
+		apiKeys[key] = scopes
+	}
+
+	var tokenSecret []byte
+	if secret := viper.GetString("web.auth.token_secret"); secret != "" {
+		tokenSecret = []byte(secret)
+	}
+
+	return &apiKeyAuthProvider{apiKeys: apiKeys, tokenSecret: tokenSecret}
+}
+
+func (p *apiKeyAuthProvider) Authenticate(authHeader string) (AuthIdentity, error) {
+	if authHeader == "" {
+		return AuthIdentity{}, fmt.Errorf("missing Authorization header")
+	}
+
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+
+	if scopes, ok := p.apiKeys[token]; ok {
+		return AuthIdentity{KeyId: keyId(token), Scopes: scopes}, nil
+	}
+
+	if p.tokenSecret != nil {
+		if identity, err := p.verifyHMACToken(token); err == nil {
+			return identity, nil
+		}
+	}
+
+	return AuthIdentity{}, fmt.Errorf("invalid API key")
+}
+
+// verifyHMACToken verifies a "<payload>.<base64url(hmac-sha256(payload))>" bearer
+// token, where payload is "<keyId>:<comma separated scopes>".
+func (p *apiKeyAuthProvider) verifyHMACToken(token string) (AuthIdentity, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return AuthIdentity{}, fmt.Errorf("malformed token")
+	}
+	payload, sig := parts[0], parts[1]
+
+	mac := hmac.New(sha256.New, p.tokenSecret)
+	mac.Write([]byte(payload))
+	expected := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	if subtle.ConstantTimeCompare([]byte(sig), []byte(expected)) != 1 {
+		return AuthIdentity{}, fmt.Errorf("invalid token signature")
+	}
+
+	payloadParts := strings.SplitN(payload, ":", 2)
+	identity := AuthIdentity{KeyId: payloadParts[0]}
+	if len(payloadParts) == 2 && payloadParts[1] != "" {
+		for _, s := range strings.Split(payloadParts[1], ",") {
+			identity.Scopes = append(identity.Scopes, AuthScope(s))
+		}
+	}
+	return identity, nil
+}
+
+// keyId returns a short, non-sensitive identifier for an API key, safe to log/rate-limit on.
+func keyId(apiKey string) string {
+	if len(apiKey) <= 8 {
+		return "key"
+	}
+	return apiKey[:8]
+}
+
+const identityContextKey = "powerpipe.auth.identity"
+
+// AuthMiddleware returns gin middleware enforcing the ApiKeyAuth security scheme declared
+// on APIService. It honors three modes, chosen via viper config:
+//
+//   - "disabled": no authentication is performed (only intended for localhost-only binds)
+//   - static API key verification via the supplied provider (the default)
+//   - HMAC-signed bearer token verification via the supplied provider, when a token
+//     secret is configured
+//
+// On success the resolved AuthIdentity is stashed in the gin context under identityContextKey
+// for downstream handlers (and the rate limiter) to read via IdentityFromContext.
+func (api *APIService) AuthMiddleware() gin.HandlerFunc {
+	if viper.GetString("web.auth.mode") == "disabled" {
+		return func(c *gin.Context) {
+			if !isLocalhost(c.Request.RemoteAddr) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "authentication is disabled; only localhost binds are permitted"})
+				return
+			}
+			c.Next()
+		}
+	}
+
+	provider := api.authProvider
+	if provider == nil {
+		provider = newAPIKeyAuthProviderFromViper()
+	}
+
+	return func(c *gin.Context) {
+		identity, err := provider.Authenticate(c.GetHeader("Authorization"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.Set(identityContextKey, identity)
+		c.Next()
+	}
+}
+
+// RequireScope returns gin middleware enforcing that the caller's AuthIdentity (as
+// resolved by AuthMiddleware, which must run first) holds scope. If no identity is on
+// the context at all, auth is running in "disabled" mode and the request has already
+// been restricted to localhost by AuthMiddleware, so the check is skipped.
+func RequireScope(scope AuthScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, ok := IdentityFromContext(c)
+		if ok && !identity.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("API key is missing the '%s' scope", scope)})
+			return
+		}
+		c.Next()
+	}
+}
+
+// IdentityFromContext returns the AuthIdentity resolved by AuthMiddleware for this request,
+// if any.
+func IdentityFromContext(c *gin.Context) (AuthIdentity, bool) {
+	v, ok := c.Get(identityContextKey)
+	if !ok {
+		return AuthIdentity{}, false
+	}
+	identity, ok := v.(AuthIdentity)
+	return identity, ok
+}
+
+func isLocalhost(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func newScopeTestRouter(identity *AuthIdentity, scope AuthScope) *gin.Engine {
+	router := gin.New()
+	router.GET("/execute", func(c *gin.Context) {
+		if identity != nil {
+			c.Set(identityContextKey, *identity)
+		}
+		c.Next()
+	}, RequireScope(scope), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func TestRequireScope_DeniesMissingScope(t *testing.T) {
+	router := newScopeTestRouter(&AuthIdentity{KeyId: "key1", Scopes: []AuthScope{AuthScopeRead}}, AuthScopeExecute)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/execute", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for an identity missing the execute scope, got %d", w.Code)
+	}
+}
+
+func TestRequireScope_AllowsGrantedScope(t *testing.T) {
+	router := newScopeTestRouter(&AuthIdentity{KeyId: "key1", Scopes: []AuthScope{AuthScopeExecute}}, AuthScopeExecute)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/execute", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for an identity holding the execute scope, got %d", w.Code)
+	}
+}
+
+func TestRequireScope_AllowsAdminScope(t *testing.T) {
+	router := newScopeTestRouter(&AuthIdentity{KeyId: "key1", Scopes: []AuthScope{AuthScopeAdmin}}, AuthScopeExecute)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/execute", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for an admin-scoped identity, got %d", w.Code)
+	}
+}
+
+// TestRequireScope_SkipsCheckWhenAuthDisabled mirrors AuthMiddleware's "disabled" mode,
+// where no AuthIdentity is ever set on the context - RequireScope must not block those
+// requests, since AuthMiddleware has already restricted them to localhost.
+func TestRequireScope_SkipsCheckWhenAuthDisabled(t *testing.T) {
+	router := newScopeTestRouter(nil, AuthScopeExecute)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/execute", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 when no identity is set (auth disabled), got %d", w.Code)
+	}
+}
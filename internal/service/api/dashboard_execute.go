@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/turbot/powerpipe/internal/dashboardexecute"
+)
+
+// defaultBatchExecuteTimeout bounds how long a batch dashboard execution is allowed to
+// run before it is cancelled, if the request does not specify one.
+const defaultBatchExecuteTimeout = 5 * time.Minute
+
+// batchStatusPollInterval is how often the stream handler checks whether a batch
+// execution has finished, so it knows when to stop streaming and emit the final
+// snapshot event.
+const batchStatusPollInterval = 50 * time.Millisecond
+
+// batchExecuteRequest is the body of POST /dashboard/{name}/execute.
+type batchExecuteRequest struct {
+	Inputs  map[string]any `json:"inputs"`
+	Timeout string         `json:"timeout"`
+	Format  string         `json:"format" binding:"omitempty,oneof=ndjson snapshot"`
+}
+
+// RegisterDashboardExecuteEndpoint registers POST /dashboard/{name}/execute, a batch
+// (non-interactive) dashboard execution endpoint that streams dashboardevents.* as
+// NDJSON, for CI/headless consumers that don't want to speak the websocket protocol.
+func (api *APIService) RegisterDashboardExecuteEndpoint(apiPrefixGroup *gin.RouterGroup) {
+	apiPrefixGroup.POST("/dashboard/:name/execute", RequireScope(AuthScopeExecute), api.handleDashboardExecute)
+}
+
+func (api *APIService) handleDashboardExecute(c *gin.Context) {
+	name := c.Param("name")
+
+	var req batchExecuteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if api.dashboardWorkspace == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "no workspace loaded"})
+		return
+	}
+
+	rootResource, ok := api.dashboardWorkspace.GetPowerpipeModResources().Dashboards[name]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("dashboard %s not found", name)})
+		return
+	}
+
+	timeout := defaultBatchExecuteTimeout
+	if req.Timeout != "" {
+		parsed, err := time.ParseDuration(req.Timeout)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid timeout: %s", err)})
+			return
+		}
+		timeout = parsed
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+
+	sessionId := fmt.Sprintf("batch-%s", uuid.NewString())
+
+	batchExecutor := dashboardexecute.NewDashboardExecutor(
+		api.dashboardExecutor.DefaultClient(),
+		dashboardexecute.WithInteractive(false),
+		dashboardexecute.WithSessionStore(api.dashboardExecutor.SessionStore()),
+		dashboardexecute.WithLogger(api.logger.Named("dashboard.exec.batch")),
+	)
+	defer batchExecutor.CancelExecutionForSession(context.Background(), sessionId)
+
+	eventCh, unsubscribe, err := batchExecutor.SubscribeSessionEvents(ctx, sessionId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer unsubscribe()
+
+	if err := batchExecutor.ExecuteDashboard(ctx, sessionId, rootResource, req.Inputs, api.dashboardWorkspace); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// format: "snapshot" waits for completion and responds with a single JSON body;
+	// the default, "ndjson", streams events as they happen. Either way we must keep
+	// draining eventCh ourselves - nothing else reads it in this code path, and an
+	// undrained channel would otherwise fill and stall (or, pre-fix, permanently block)
+	// the session store's publisher.
+	if req.Format == "snapshot" {
+		go drainEvents(ctx, eventCh)
+		api.waitForBatchExecution(ctx, batchExecutor, sessionId)
+		if err := ctx.Err(); err != nil {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": err.Error()})
+			return
+		}
+		panels, err := batchExecutor.Snapshot(sessionId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"event": "snapshot", "panels": panels})
+		return
+	}
+
+	// stream dashboardevents.* as newline-delimited JSON, one event per line, flushed
+	// immediately - mirror the gzip exclusion already applied to .jsonl paths so this
+	// isn't buffered by the compression middleware
+	c.Header("Content-Type", "application/x-ndjson")
+
+	ticker := time.NewTicker(batchStatusPollInterval)
+	defer ticker.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case data := <-eventCh:
+			_, _ = w.Write(data)
+			_, _ = w.Write([]byte("\n"))
+			return true
+		case <-ticker.C:
+			if !batchExecutor.IsSessionFinished(sessionId) {
+				return true
+			}
+			// drain any events already buffered before closing out
+			for {
+				select {
+				case data := <-eventCh:
+					_, _ = w.Write(data)
+					_, _ = w.Write([]byte("\n"))
+				default:
+					writeSnapshotEvent(w, batchExecutor, sessionId)
+					return false
+				}
+			}
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
+
+// drainEvents discards events from ch until ctx is done or ch is closed, so that a
+// consumer which only cares about the final snapshot (format: "snapshot") doesn't
+// leave the channel undrained.
+func drainEvents(ctx context.Context, ch <-chan []byte) {
+	for {
+		select {
+		case _, open := <-ch:
+			if !open {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// waitForBatchExecution blocks until sessionId's execution finishes or ctx is done.
+func (api *APIService) waitForBatchExecution(ctx context.Context, batchExecutor *dashboardexecute.DashboardExecutor, sessionId string) {
+	ticker := time.NewTicker(batchStatusPollInterval)
+	defer ticker.Stop()
+	for {
+		if batchExecutor.IsSessionFinished(sessionId) {
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// writeSnapshotEvent writes the final {"event":"snapshot","panels":{...}} NDJSON line
+// once a batch execution has completed.
+func writeSnapshotEvent(w io.Writer, batchExecutor *dashboardexecute.DashboardExecutor, sessionId string) {
+	panels, err := batchExecutor.Snapshot(sessionId)
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(gin.H{"event": "snapshot", "panels": panels})
+	if err != nil {
+		return
+	}
+	_, _ = w.Write(data)
+	_, _ = w.Write([]byte("\n"))
+}
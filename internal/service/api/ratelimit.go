@@ -0,0 +1,26 @@
+package api
+
+import (
+	"github.com/didip/tollbooth/v7"
+	"github.com/didip/tollbooth/v7/limiter"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitMiddleware returns gin middleware enforcing lmt, keying buckets by API key
+// (if AuthMiddleware has populated an identity on the context) and falling back to the
+// caller's remote IP otherwise.
+func RateLimitMiddleware(lmt *limiter.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.ClientIP()
+		if identity, ok := IdentityFromContext(c); ok && identity.KeyId != "" {
+			key = identity.KeyId
+		}
+
+		if httpError := tollbooth.LimitByKeys(lmt, []string{key}); httpError != nil {
+			c.Data(httpError.StatusCode, lmt.GetMessageContentType(), []byte(httpError.Message))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
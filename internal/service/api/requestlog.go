@@ -0,0 +1,45 @@
+package api
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/turbot/powerpipe/internal/logging"
+)
+
+// requestIdHeader is the header clients may set to correlate a request across logs;
+// if absent, a new one is generated and echoed back on the response.
+const requestIdHeader = "X-Request-ID"
+
+// RequestLoggerMiddleware returns gin middleware which emits one structured log record
+// per request via logger, in place of gin's default logger.
+func RequestLoggerMiddleware(logger logging.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestId := c.GetHeader(requestIdHeader)
+		if requestId == "" {
+			requestId = uuid.NewString()
+		}
+		c.Header(requestIdHeader, requestId)
+
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+
+		apiKeyId := ""
+		if identity, ok := IdentityFromContext(c); ok {
+			apiKeyId = identity.KeyId
+		}
+
+		logger.Info("request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"latency", latency.String(),
+			"remote", c.ClientIP(),
+			"api_key_id", apiKeyId,
+			"request_id", requestId,
+		)
+	}
+}
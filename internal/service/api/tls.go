@@ -0,0 +1,157 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/spf13/viper"
+	"github.com/turbot/pipe-fittings/filepaths"
+)
+
+// configureTLS builds the tls.Config to use for api.httpsServer, picking a
+// cert source based on viper config, checked in this order:
+//
+//  1. ACME/Let's Encrypt via autocert, if web.tls.acme.allowed_hosts is set
+//  2. user-supplied cert/key files (web.tls.cert_file / web.tls.key_file)
+//  3. a self-signed cert generated into filepaths.EnsureConfigDir(), for local dev
+//
+// When autocert is in use, api.acmeManager is set so Start can serve the HTTP-01
+// challenge handler on a plain-HTTP listener.
+func (api *APIService) configureTLS() (*tls.Config, error) {
+	certFile := viper.GetString("web.tls.cert_file")
+	keyFile := viper.GetString("web.tls.key_file")
+	allowedHosts := viper.GetStringSlice("web.tls.acme.allowed_hosts")
+
+	switch {
+	case len(allowedHosts) > 0:
+		return api.configureAutocertTLS(allowedHosts)
+	case certFile != "" && keyFile != "":
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS cert/key: %w", err)
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+	default:
+		certFile, keyFile, err := ensureSelfSignedCert(api.HTTPSHost)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate self-signed cert: %w", err)
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load generated self-signed cert: %w", err)
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+	}
+}
+
+// configureAutocertTLS builds an autocert-backed tls.Config restricted to allowedHosts,
+// caching issued certs under the powerpipe config dir. It stores the manager on
+// api.acmeManager so Start can serve the HTTP-01 challenge handler (manager.HTTPHandler)
+// on a plain-HTTP listener - the challenge must be reachable over HTTP, not HTTPS.
+func (api *APIService) configureAutocertTLS(allowedHosts []string) (*tls.Config, error) {
+	configDir, err := filepaths.EnsureConfigDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to ensure config dir for acme cache: %w", err)
+	}
+	cacheDir := filepath.Join(configDir, "acme-cache")
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create acme cache dir: %w", err)
+	}
+
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(allowedHosts...),
+		Cache:      autocert.DirCache(cacheDir),
+	}
+
+	api.acmeManager = manager
+
+	return manager.TLSConfig(), nil
+}
+
+// ensureSelfSignedCert returns the paths to a cert/key pair under
+// filepaths.EnsureConfigDir(), generating a new self-signed pair for host if one
+// does not already exist.
+func ensureSelfSignedCert(host string) (certFile, keyFile string, err error) {
+	configDir, err := filepaths.EnsureConfigDir()
+	if err != nil {
+		return "", "", err
+	}
+	certFile = filepath.Join(configDir, "server.crt")
+	keyFile = filepath.Join(configDir, "server.key")
+
+	if _, err := os.Stat(certFile); err == nil {
+		if _, err := os.Stat(keyFile); err == nil {
+			return certFile, keyFile, nil
+		}
+	}
+
+	if host == "" {
+		host = "localhost"
+	}
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+
+	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
+	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+	if err != nil {
+		return "", "", err
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{Organization: []string{"Powerpipe (dev)"}},
+		DNSNames:              []string{host},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	if err != nil {
+		return "", "", err
+	}
+
+	certOut, err := os.OpenFile(certFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return "", "", err
+	}
+	defer certOut.Close()
+	if err := pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: derBytes}); err != nil {
+		return "", "", err
+	}
+
+	keyOut, err := os.OpenFile(keyFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", "", err
+	}
+	defer keyOut.Close()
+	keyBytes, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		return "", "", err
+	}
+	if err := pem.Encode(keyOut, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}); err != nil {
+		return "", "", err
+	}
+
+	return certFile, keyFile, nil
+}